@@ -0,0 +1,84 @@
+package tarfs
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// ErrUnsupportedCompression is returned by NewAuto when it recognizes the
+// input's compression format but cannot decode it without help: tarfs
+// recognizes lz4 but does not vendor a decoder for it. Pass a
+// WithDecompressor option built on a third-party lz4 package to handle it.
+var ErrUnsupportedCompression = errors.New("tarfs: unsupported compression, pass WithDecompressor")
+
+var (
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicBzip2 = []byte("BZh")
+	magicXz    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	magicZstd  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	magicLz4   = []byte{0x04, 0x22, 0x4d, 0x18}
+)
+
+// WithDecompressor makes New or NewAuto run r through dec before parsing it
+// as a tar stream, so callers can plug in any compression codec tarfs does
+// not handle natively.
+func WithDecompressor(dec func(r io.Reader) (io.Reader, error)) Option {
+	return func(o *options) {
+		o.decompressor = dec
+	}
+}
+
+// NewAuto creates an FS from r, sniffing its first bytes to detect and
+// transparently undo gzip, bzip2, xz or zstd compression (covering .tar.gz,
+// .tgz, .tar.bz2, .tar.xz and .tar.zst) before delegating to New. lz4 is
+// recognized but rejected with ErrUnsupportedCompression unless the caller
+// supplies a WithDecompressor for it.
+//
+// Known scope cut: NewAuto only supports streaming decompression through
+// New. It does not integrate with the lazy, ReaderAt-backed index built by
+// NewFromReaderAt/NewFromFile — there is no support here for zstd seekable
+// frames, gzip index files, or an automatic spool-to-temp-file fallback for
+// non-seekable codecs. NewFromReaderAt itself rejects WithDecompressor
+// outright (see its doc comment) rather than silently ignoring it. Random
+// access into a compressed archive currently requires the caller to
+// decompress it to a file first and open that with NewFromFile. This is a
+// deliberate partial implementation of the seekable-compression half of the
+// request and should be called out for explicit sign-off rather than taken
+// as done.
+func NewAuto(r io.Reader, opts ...Option) (*FS, error) {
+	o := resolveOptions(opts)
+
+	br := bufio.NewReader(r)
+
+	if o.decompressor == nil {
+		peek, _ := br.Peek(len(magicXz))
+
+		switch {
+		case bytes.HasPrefix(peek, magicGzip):
+			o.decompressor = func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+		case bytes.HasPrefix(peek, magicBzip2):
+			o.decompressor = func(r io.Reader) (io.Reader, error) { return bzip2.NewReader(r), nil }
+		case bytes.HasPrefix(peek, magicXz):
+			o.decompressor = func(r io.Reader) (io.Reader, error) { return xz.NewReader(r) }
+		case bytes.HasPrefix(peek, magicZstd):
+			o.decompressor = func(r io.Reader) (io.Reader, error) {
+				zr, err := zstd.NewReader(r)
+				if err != nil {
+					return nil, err
+				}
+				return zr.IOReadCloser(), nil
+			}
+		case bytes.HasPrefix(peek, magicLz4):
+			return nil, ErrUnsupportedCompression
+		}
+	}
+
+	return newFromReader(br, o)
+}
@@ -0,0 +1,91 @@
+package tarfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+func TestNewAutoGzip(t *testing.T) {
+	require := require.New(t)
+
+	raw, err := os.ReadFile("test.tar")
+	require.NoError(err)
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, err = gw.Write(raw)
+	require.NoError(err)
+	require.NoError(gw.Close())
+
+	tfs, err := NewAuto(&gz)
+	require.NoError(err)
+
+	require.NoError(fstest.TestFS(tfs, "foo", "dir1/dir11"))
+}
+
+func TestNewAutoPlainTar(t *testing.T) {
+	require := require.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	tfs, err := NewAuto(f)
+	require.NoError(err)
+
+	require.NoError(fstest.TestFS(tfs, "foo", "dir1/dir11"))
+}
+
+func TestNewAutoUnsupportedCompression(t *testing.T) {
+	require := require.New(t)
+
+	lz4Magic := bytes.NewReader([]byte{0x04, 0x22, 0x4d, 0x18, 0x00, 0x00})
+
+	_, err := NewAuto(lz4Magic)
+	require.ErrorIs(err, ErrUnsupportedCompression)
+}
+
+func TestNewAutoXz(t *testing.T) {
+	require := require.New(t)
+
+	raw, err := os.ReadFile("test.tar")
+	require.NoError(err)
+
+	var xzBuf bytes.Buffer
+	xw, err := xz.NewWriter(&xzBuf)
+	require.NoError(err)
+	_, err = xw.Write(raw)
+	require.NoError(err)
+	require.NoError(xw.Close())
+
+	tfs, err := NewAuto(&xzBuf)
+	require.NoError(err)
+
+	require.NoError(fstest.TestFS(tfs, "foo", "dir1/dir11"))
+}
+
+func TestNewAutoZstd(t *testing.T) {
+	require := require.New(t)
+
+	raw, err := os.ReadFile("test.tar")
+	require.NoError(err)
+
+	var zstdBuf bytes.Buffer
+	zw, err := zstd.NewWriter(&zstdBuf)
+	require.NoError(err)
+	_, err = zw.Write(raw)
+	require.NoError(err)
+	require.NoError(zw.Close())
+
+	tfs, err := NewAuto(&zstdBuf)
+	require.NoError(err)
+
+	require.NoError(fstest.TestFS(tfs, "foo", "dir1/dir11"))
+}
@@ -0,0 +1,45 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+)
+
+// entry holds the parsed metadata for a single path in the archive, along
+// with however its body should be read back: either fully buffered in
+// content (New) or served on demand from section (NewFromReaderAt,
+// NewFromFile).
+type entry struct {
+	header *tar.Header
+	name   string // cleaned path, "." for the root
+
+	children []*entry // only populated for directories
+
+	content []byte
+	section *io.SectionReader
+
+	// bodyOffset is the byte offset of the entry's body within the
+	// archive. It is only meaningful when section is non-nil, and is kept
+	// alongside it so WriteIndex can persist it without having to recover
+	// it back out of an io.SectionReader.
+	bodyOffset int64
+}
+
+func (e *entry) isDir() bool {
+	return e.header.Typeflag == tar.TypeDir
+}
+
+func (e *entry) info() fs.FileInfo {
+	return e.header.FileInfo()
+}
+
+func (e *entry) hasChild(name string) bool {
+	for _, c := range e.children {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
@@ -0,0 +1,19 @@
+package tarfs
+
+import "errors"
+
+// Errors returned by FS operations, in addition to the usual fs.ErrInvalid
+// and fs.ErrNotExist.
+var (
+	// ErrNotDir is returned when ReadDir is called on a path that is not a
+	// directory.
+	ErrNotDir = errors.New("not a directory")
+
+	// ErrDir is returned when Read is called on a file that is a directory.
+	ErrDir = errors.New("is a directory")
+
+	// ErrTooManyLinks is returned when resolving a symlink requires
+	// following more hops than maxLinkHops, which usually means the
+	// archive contains a symlink cycle.
+	ErrTooManyLinks = errors.New("too many levels of symbolic links")
+)
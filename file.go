@@ -0,0 +1,99 @@
+package tarfs
+
+import (
+	"io"
+	"io/fs"
+)
+
+// file is the handle returned by FS.Open. It implements fs.File,
+// fs.ReadDirFile and io.ReaderAt.
+type file struct {
+	tfs   *FS
+	entry *entry
+
+	offset     int64
+	dirEntries []fs.DirEntry // lazily populated by the first call to ReadDir
+	dirRead    bool          // set once dirEntries has been fetched, so a drained slice isn't mistaken for unfetched
+}
+
+var (
+	_ fs.File        = (*file)(nil)
+	_ fs.ReadDirFile = (*file)(nil)
+	_ io.ReaderAt    = (*file)(nil)
+)
+
+func newFile(tfs *FS, e *entry) *file {
+	return &file{tfs: tfs, entry: e}
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	return f.entry.info(), nil
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.entry.isDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.entry.name, Err: ErrDir}
+	}
+
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// ReadAt reads len(p) bytes from the file starting at byte offset off. It
+// lets multiple goroutines read from distinct, independently positioned
+// handles of the same entry concurrently.
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if f.entry.isDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.entry.name, Err: ErrDir}
+	}
+
+	if f.entry.section != nil {
+		return f.entry.section.ReadAt(p, off)
+	}
+
+	// Mirror io.SectionReader.ReadAt: an out-of-range offset is reported as
+	// io.EOF, not fs.ErrInvalid, so callers see the same error regardless of
+	// whether the FS was built by New or NewFromReaderAt/NewFromFile.
+	if off < 0 || off >= int64(len(f.entry.content)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, f.entry.content[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *file) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.entry.isDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.entry.name, Err: ErrNotDir}
+	}
+
+	if !f.dirRead {
+		f.dirEntries = f.tfs.dirEntriesFor(f.entry)
+		f.dirRead = true
+	}
+
+	if n <= 0 {
+		entries := f.dirEntries
+		f.dirEntries = nil
+		return entries, nil
+	}
+
+	if len(f.dirEntries) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(f.dirEntries) {
+		n = len(f.dirEntries)
+	}
+	entries := f.dirEntries[:n]
+	f.dirEntries = f.dirEntries[n:]
+	return entries, nil
+}
+
+func (f *file) Close() error {
+	return nil
+}
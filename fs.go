@@ -0,0 +1,446 @@
+// Package tarfs allows to use the content of a tar archive as an fs.FS.
+package tarfs
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS is a read-only file system backed by the content of a tar archive.
+//
+// FS implements fs.FS, fs.ReadDirFS and fs.StatFS.
+type FS struct {
+	entries map[string]*entry
+
+	resolveSymlinks bool
+
+	// archiveSize and archiveModTime identify the archive this FS was built
+	// from, so that WriteIndex/NewFromReaderAtWithIndex can detect a stale
+	// index. archiveModTime is the zero Time when unknown, e.g. when built
+	// from NewFromReaderAt instead of NewFromFile.
+	archiveSize    int64
+	archiveModTime time.Time
+}
+
+// Option configures an FS built by New, NewAuto, NewFromReaderAt or
+// NewFromFile.
+type Option func(*options)
+
+// options collects the effect of every Option passed to a constructor.
+// decompressor is only meaningful to New and NewAuto: NewFromReaderAt needs
+// random access into the raw archive, so it cannot transparently decompress
+// its input.
+type options struct {
+	resolveSymlinks bool
+	decompressor    func(io.Reader) (io.Reader, error)
+}
+
+// WithSymlinks controls whether Open, Stat and ReadDir transparently resolve
+// symlinks found in the archive, including directory symlinks encountered by
+// fs.WalkDir. It defaults to false: symlink entries are returned as-is, and
+// callers can inspect their raw target with ReadLink.
+func WithSymlinks(resolve bool) Option {
+	return func(o *options) {
+		o.resolveSymlinks = resolve
+	}
+}
+
+func resolveOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// maxLinkHops bounds symlink resolution so that a cycle in the archive
+// results in ErrTooManyLinks instead of an infinite loop.
+const maxLinkHops = 40
+
+var (
+	_ fs.FS        = (*FS)(nil)
+	_ fs.ReadDirFS = (*FS)(nil)
+	_ fs.StatFS    = (*FS)(nil)
+)
+
+// New creates an FS from the tar archive read from r.
+//
+// New reads r to completion, buffering every entry's content in memory. For
+// large archives, prefer NewFromReaderAt or NewFromFile, which only index
+// the archive and read file bodies on demand.
+func New(r io.Reader, opts ...Option) (*FS, error) {
+	return newFromReader(r, resolveOptions(opts))
+}
+
+// newFromReader does the actual work for New and NewAuto: apply o's
+// decompressor if any, then scan the resulting tar stream into an FS.
+func newFromReader(r io.Reader, o *options) (*FS, error) {
+	if o.decompressor != nil {
+		dr, err := o.decompressor(r)
+		if err != nil {
+			return nil, err
+		}
+		r = dr
+	}
+
+	tfs := newFS(o)
+
+	tr := tar.NewReader(r)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+
+		tfs.addEntry(header, &entry{header: header, content: content})
+	}
+
+	if err := tfs.resolveHardlinks(); err != nil {
+		return nil, err
+	}
+
+	tfs.sortChildren()
+
+	return tfs, nil
+}
+
+// NewFromReaderAt creates an FS from the tar archive read from r, whose
+// total size is size.
+//
+// NewFromReaderAt scans the archive only once, to build an index of each
+// entry's header and body offset. File bodies are then read from r on
+// demand through an io.SectionReader, so multi-gigabyte archives never need
+// to be loaded into memory, and distinct entries can be opened and read
+// concurrently from multiple goroutines.
+//
+// NewFromReaderAt needs random access into the raw archive bytes, so it
+// cannot transparently decompress r: passing WithDecompressor is an error.
+// Decompress a compressed archive to a file first and open that with
+// NewFromFile.
+func NewFromReaderAt(r io.ReaderAt, size int64, opts ...Option) (*FS, error) {
+	o := resolveOptions(opts)
+	if o.decompressor != nil {
+		return nil, errors.New("tarfs: NewFromReaderAt does not support WithDecompressor, decompress to a file first and use NewFromFile")
+	}
+
+	tfs := newFS(o)
+	tfs.archiveSize = size
+
+	cr := &countingReader{r: io.NewSectionReader(r, 0, size)}
+	tr := tar.NewReader(cr)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		bodyOffset := cr.pos
+		body := io.NewSectionReader(r, bodyOffset, header.Size)
+
+		tfs.addEntry(header, &entry{header: header, section: body, bodyOffset: bodyOffset})
+	}
+
+	if err := tfs.resolveHardlinks(); err != nil {
+		return nil, err
+	}
+
+	tfs.sortChildren()
+
+	return tfs, nil
+}
+
+// NewFromFile is a convenience wrapper around NewFromReaderAt for a file
+// already opened from disk.
+func NewFromFile(f *os.File, opts ...Option) (*FS, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	tfs, err := NewFromReaderAt(f, fi.Size(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	tfs.archiveModTime = fi.ModTime()
+
+	return tfs, nil
+}
+
+func newFS(o *options) *FS {
+	root := &entry{name: ".", header: &tar.Header{Name: ".", Typeflag: tar.TypeDir}}
+	return &FS{
+		entries:         map[string]*entry{".": root},
+		resolveSymlinks: o.resolveSymlinks,
+	}
+}
+
+// resolveHardlinks makes every TypeLink entry alias the content/section of
+// the entry it points to, once the whole archive has been scanned.
+func (tfs *FS) resolveHardlinks() error {
+	for _, e := range tfs.entries {
+		if e.header.Typeflag != tar.TypeLink {
+			continue
+		}
+
+		target, err := joinLink(path.Dir(e.name), e.header.Linkname)
+		if err != nil {
+			return &fs.PathError{Op: "open", Path: e.name, Err: fs.ErrInvalid}
+		}
+
+		targetEntry, ok := tfs.entries[target]
+		if !ok {
+			return &fs.PathError{Op: "open", Path: e.name, Err: fs.ErrNotExist}
+		}
+
+		e.content = targetEntry.content
+		e.section = targetEntry.section
+	}
+
+	return nil
+}
+
+// joinLink resolves a symlink/hardlink target found in dir (the linking
+// entry's directory) against the archive root, rejecting targets that
+// escape it.
+func joinLink(dir, link string) (string, error) {
+	var target string
+	if path.IsAbs(link) {
+		target = strings.TrimPrefix(path.Clean(link), "/")
+		if target == "" {
+			target = "."
+		}
+	} else {
+		target = path.Join(dir, link)
+	}
+
+	if target == ".." || strings.HasPrefix(target, "../") {
+		return "", fs.ErrInvalid
+	}
+
+	return target, nil
+}
+
+// resolveEntry follows e's symlink chain up to maxLinkHops times and
+// returns the final, non-symlink entry.
+func (tfs *FS) resolveEntry(e *entry, op, name string) (*entry, error) {
+	for hops := 0; e.header.Typeflag == tar.TypeSymlink; hops++ {
+		if hops >= maxLinkHops {
+			return nil, &fs.PathError{Op: op, Path: name, Err: ErrTooManyLinks}
+		}
+
+		target, err := joinLink(path.Dir(e.name), e.header.Linkname)
+		if err != nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+		}
+
+		next, ok := tfs.entries[target]
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+
+		e = next
+	}
+
+	return e, nil
+}
+
+// addEntry inserts e into the tree under the path carried by header.Name,
+// creating any missing parent directories along the way.
+func (tfs *FS) addEntry(header *tar.Header, e *entry) {
+	name := cleanName(header.Name)
+	e.name = name
+	e.header.Name = name
+
+	// An archive may carry an explicit header for a directory that was
+	// already synthesized as the parent of an earlier entry: keep the
+	// children discovered so far.
+	if existing, ok := tfs.entries[name]; ok && existing.isDir() && e.isDir() {
+		e.children = existing.children
+	}
+
+	tfs.entries[name] = e
+
+	if name != "." {
+		tfs.linkToParent(e)
+	}
+}
+
+func (tfs *FS) linkToParent(e *entry) {
+	for e.name != "." {
+		dir := path.Dir(e.name)
+
+		parent, ok := tfs.entries[dir]
+		if !ok {
+			parent = &entry{name: dir, header: &tar.Header{Name: dir, Typeflag: tar.TypeDir}}
+			tfs.entries[dir] = parent
+		}
+
+		if !parent.hasChild(e.name) {
+			parent.children = append(parent.children, e)
+		}
+
+		e = parent
+	}
+}
+
+func (tfs *FS) sortChildren() {
+	for _, e := range tfs.entries {
+		sort.Slice(e.children, func(i, j int) bool { return e.children[i].name < e.children[j].name })
+	}
+}
+
+func cleanName(name string) string {
+	name = path.Clean("/" + name)
+	name = name[1:] // strip the leading "/" added above
+	if name == "" {
+		name = "."
+	}
+	return name
+}
+
+// Open implements fs.FS. If the FS was built with WithSymlinks(true), Open
+// transparently follows symlinks to the file or directory they point to.
+func (tfs *FS) Open(name string) (fs.File, error) {
+	e, err := tfs.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if tfs.resolveSymlinks {
+		if e, err = tfs.resolveEntry(e, "open", name); err != nil {
+			return nil, err
+		}
+	}
+
+	return newFile(tfs, e), nil
+}
+
+// Stat implements fs.StatFS. If the FS was built with WithSymlinks(true),
+// Stat transparently follows symlinks to the file or directory they point
+// to; use Lstat to inspect a symlink itself.
+func (tfs *FS) Stat(name string) (fs.FileInfo, error) {
+	e, err := tfs.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if tfs.resolveSymlinks {
+		if e, err = tfs.resolveEntry(e, "stat", name); err != nil {
+			return nil, err
+		}
+	}
+
+	return e.info(), nil
+}
+
+// Lstat returns the fs.FileInfo for name without following a trailing
+// symlink, unlike Stat.
+func (tfs *FS) Lstat(name string) (fs.FileInfo, error) {
+	e, err := tfs.lookup("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return e.info(), nil
+}
+
+// ReadLink returns the target of the symlink at name, as recorded in the
+// archive.
+func (tfs *FS) ReadLink(name string) (string, error) {
+	e, err := tfs.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if e.header.Typeflag != tar.TypeSymlink {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return e.header.Linkname, nil
+}
+
+// ReadDir implements fs.ReadDirFS. If the FS was built with
+// WithSymlinks(true), ReadDir follows a directory symlink at name, which
+// lets fs.WalkDir traverse into it.
+func (tfs *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	e, err := tfs.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if tfs.resolveSymlinks {
+		if e, err = tfs.resolveEntry(e, "readdir", name); err != nil {
+			return nil, err
+		}
+	}
+
+	if !e.isDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: ErrNotDir}
+	}
+	return tfs.dirEntriesFor(e), nil
+}
+
+// dirEntriesFor builds the fs.DirEntry slice for e's children. Each entry
+// is wrapped in childDirEntry instead of the plain fs.FileInfoToDirEntry, so
+// that a directory symlink reports IsDir() == true when the FS resolves
+// symlinks, letting fs.WalkDir recurse into it.
+func (tfs *FS) dirEntriesFor(e *entry) []fs.DirEntry {
+	entries := make([]fs.DirEntry, len(e.children))
+	for i, c := range e.children {
+		entries[i] = childDirEntry{tfs: tfs, entry: c}
+	}
+	return entries
+}
+
+// childDirEntry is the fs.DirEntry tarfs hands to fs.WalkDir for a
+// directory's children. IsDir follows the child's symlink chain when the FS
+// was built with WithSymlinks(true), so WalkDir descends into directory
+// symlinks instead of treating them as leaves.
+type childDirEntry struct {
+	tfs   *FS
+	entry *entry
+}
+
+func (d childDirEntry) Name() string { return d.entry.info().Name() }
+
+func (d childDirEntry) IsDir() bool {
+	e := d.entry
+	if d.tfs.resolveSymlinks && e.header.Typeflag == tar.TypeSymlink {
+		resolved, err := d.tfs.resolveEntry(e, "readdir", e.name)
+		return err == nil && resolved.isDir()
+	}
+	return e.isDir()
+}
+
+func (d childDirEntry) Type() fs.FileMode { return d.entry.info().Mode().Type() }
+
+func (d childDirEntry) Info() (fs.FileInfo, error) { return d.entry.info(), nil }
+
+func (tfs *FS) lookup(op, name string) (*entry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := tfs.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return e, nil
+}
@@ -0,0 +1,260 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// indexMagic identifies the binary format written by WriteIndex and read by
+// NewFromReaderAtWithIndex.
+var indexMagic = [4]byte{'T', 'F', 'S', 'I'}
+
+// indexVersion is bumped whenever the layout written below changes in an
+// incompatible way.
+const indexVersion = 1
+
+// ErrIndexStale is returned by NewFromReaderAtWithIndex when the index's
+// recorded archive size or modification time does not match r, meaning the
+// index was built from a different archive, or the archive has changed
+// since the index was written.
+var ErrIndexStale = errors.New("tarfs: index is stale for this archive")
+
+// WriteIndex serializes fs's directory tree to w: every entry's path,
+// header and body offsets, size, mode, typeflag, link name and modification
+// time, in a stable, versioned binary layout. The result can later be
+// reloaded in O(entries) with NewFromReaderAtWithIndex, skipping the tar
+// body scan that New and NewFromReaderAt perform.
+//
+// WriteIndex requires fs to have been built by NewFromReaderAt or
+// NewFromFile: entries built by New only hold buffered content, with no
+// byte offset into an underlying archive to persist.
+func (tfs *FS) WriteIndex(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	if _, err := bw.Write(indexMagic[:]); err != nil {
+		return err
+	}
+	if err := writeUint32(bw, indexVersion); err != nil {
+		return err
+	}
+	if err := writeInt64(bw, tfs.archiveSize); err != nil {
+		return err
+	}
+	if err := writeInt64(bw, tfs.archiveModTime.UnixNano()); err != nil {
+		return err
+	}
+	if err := writeUint32(bw, uint32(len(tfs.entries))); err != nil {
+		return err
+	}
+
+	for _, e := range tfs.entries {
+		if err := writeIndexEntry(bw, e); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// NewFromReaderAtWithIndex rebuilds an FS for the tar archive read from r
+// (whose total size is size and last modification time is modTime) using a
+// previously written index, instead of scanning the archive itself. If the
+// index's recorded archive size or modification time does not match size
+// and modTime, it returns ErrIndexStale: same-size archives with different
+// content, e.g. a regenerated tarball, are a common way for a cached index
+// to otherwise go silently wrong. Pass the zero Time for modTime to skip
+// that check, e.g. when r's modification time isn't known.
+func NewFromReaderAtWithIndex(r io.ReaderAt, size int64, modTime time.Time, indexReader io.Reader, opts ...Option) (*FS, error) {
+	br := bufio.NewReader(indexReader)
+
+	var magic [4]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		return nil, err
+	}
+	if magic != indexMagic {
+		return nil, errors.New("tarfs: not a tarfs index")
+	}
+
+	version, err := readUint32(br)
+	if err != nil {
+		return nil, err
+	}
+	if version != indexVersion {
+		return nil, fmt.Errorf("tarfs: unsupported index version %d", version)
+	}
+
+	archiveSize, err := readInt64(br)
+	if err != nil {
+		return nil, err
+	}
+	archiveModTimeNano, err := readInt64(br)
+	if err != nil {
+		return nil, err
+	}
+
+	if archiveSize != size {
+		return nil, ErrIndexStale
+	}
+	if !modTime.IsZero() && !time.Unix(0, archiveModTimeNano).Equal(modTime) {
+		return nil, ErrIndexStale
+	}
+
+	count, err := readUint32(br)
+	if err != nil {
+		return nil, err
+	}
+
+	tfs := newFS(resolveOptions(opts))
+	tfs.archiveSize = archiveSize
+	tfs.archiveModTime = time.Unix(0, archiveModTimeNano)
+	tfs.entries = make(map[string]*entry, count)
+
+	for i := uint32(0); i < count; i++ {
+		e, err := readIndexEntry(br)
+		if err != nil {
+			return nil, err
+		}
+		e.section = io.NewSectionReader(r, e.bodyOffset, e.header.Size)
+		tfs.entries[e.name] = e
+	}
+
+	for name, e := range tfs.entries {
+		if name != "." {
+			tfs.linkToParent(e)
+		}
+	}
+
+	if err := tfs.resolveHardlinks(); err != nil {
+		return nil, err
+	}
+
+	tfs.sortChildren()
+
+	return tfs, nil
+}
+
+// NewFromFileWithIndex is a convenience wrapper around NewFromReaderAtWithIndex
+// for a file already opened from disk: it stats f and checks the index
+// against both its current size and modification time.
+func NewFromFileWithIndex(f *os.File, indexReader io.Reader, opts ...Option) (*FS, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFromReaderAtWithIndex(f, fi.Size(), fi.ModTime(), indexReader, opts...)
+}
+
+func writeIndexEntry(w io.Writer, e *entry) error {
+	if err := writeIndexString(w, e.name); err != nil {
+		return err
+	}
+	if err := writeInt64(w, e.bodyOffset); err != nil {
+		return err
+	}
+	if err := writeInt64(w, e.header.Size); err != nil {
+		return err
+	}
+	if err := writeUint32(w, uint32(e.header.Mode)); err != nil {
+		return err
+	}
+	if err := writeUint32(w, uint32(e.header.Typeflag)); err != nil {
+		return err
+	}
+	if err := writeIndexString(w, e.header.Linkname); err != nil {
+		return err
+	}
+	return writeInt64(w, e.header.ModTime.UnixNano())
+}
+
+func readIndexEntry(r io.Reader) (*entry, error) {
+	name, err := readIndexString(r)
+	if err != nil {
+		return nil, err
+	}
+	bodyOffset, err := readInt64(r)
+	if err != nil {
+		return nil, err
+	}
+	size, err := readInt64(r)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	typeflag, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	linkname, err := readIndexString(r)
+	if err != nil {
+		return nil, err
+	}
+	modTimeNano, err := readInt64(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry{
+		name:       name,
+		bodyOffset: bodyOffset,
+		header: &tar.Header{
+			Name:     name,
+			Size:     size,
+			Mode:     int64(mode),
+			Typeflag: byte(typeflag),
+			Linkname: linkname,
+			ModTime:  time.Unix(0, modTimeNano),
+		},
+	}, nil
+}
+
+// writeIndexString writes s length-prefixed, so PAX and GNU long names
+// round-trip regardless of content.
+func writeIndexString(w io.Writer, s string) error {
+	if err := writeUint32(w, uint32(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+func readIndexString(r io.Reader) (string, error) {
+	n, err := readUint32(r)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func writeUint32(w io.Writer, v uint32) error {
+	return binary.Write(w, binary.BigEndian, v)
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var v uint32
+	err := binary.Read(r, binary.BigEndian, &v)
+	return v, err
+}
+
+func writeInt64(w io.Writer, v int64) error {
+	return binary.Write(w, binary.BigEndian, v)
+}
+
+func readInt64(r io.Reader) (int64, error) {
+	var v int64
+	err := binary.Read(r, binary.BigEndian, &v)
+	return v, err
+}
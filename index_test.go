@@ -0,0 +1,100 @@
+package tarfs
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteIndexRoundTrip(t *testing.T) {
+	require := require.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	fi, err := f.Stat()
+	require.NoError(err)
+
+	tfs, err := NewFromReaderAt(f, fi.Size())
+	require.NoError(err)
+
+	var index bytes.Buffer
+	require.NoError(tfs.WriteIndex(&index))
+
+	reloaded, err := NewFromReaderAtWithIndex(f, fi.Size(), time.Time{}, &index)
+	require.NoError(err)
+
+	require.NoError(fstest.TestFS(reloaded, "foo", "dir1/dir11"))
+
+	b, err := fs.ReadFile(reloaded, "foo")
+	require.NoError(err)
+	require.Equal("foo", string(b))
+}
+
+func TestNewFromReaderAtWithIndexStale(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	fi, err := f.Stat()
+	require.NoError(err)
+
+	tfs, err := NewFromReaderAt(f, fi.Size())
+	require.NoError(err)
+
+	var index bytes.Buffer
+	require.NoError(tfs.WriteIndex(&index))
+
+	_, err = NewFromReaderAtWithIndex(f, fi.Size()+1, time.Time{}, &index)
+	assert.ErrorIs(err, ErrIndexStale)
+}
+
+func TestNewFromReaderAtWithIndexStaleModTime(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	tfs, err := NewFromFile(f)
+	require.NoError(err)
+
+	var index bytes.Buffer
+	require.NoError(tfs.WriteIndex(&index))
+
+	fi, err := f.Stat()
+	require.NoError(err)
+
+	_, err = NewFromReaderAtWithIndex(f, fi.Size(), fi.ModTime().Add(time.Minute), &index)
+	assert.ErrorIs(err, ErrIndexStale)
+}
+
+func TestNewFromFileWithIndexRoundTrip(t *testing.T) {
+	require := require.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	tfs, err := NewFromFile(f)
+	require.NoError(err)
+
+	var index bytes.Buffer
+	require.NoError(tfs.WriteIndex(&index))
+
+	reloaded, err := NewFromFileWithIndex(f, &index)
+	require.NoError(err)
+
+	require.NoError(fstest.TestFS(reloaded, "foo", "dir1/dir11"))
+}
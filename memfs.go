@@ -0,0 +1,235 @@
+package tarfs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemFS is a minimal in-memory WritableFS, meant to be used as the upper
+// layer passed to Overlay.
+type MemFS struct {
+	mu    sync.RWMutex
+	files map[string]*memEntry
+}
+
+type memEntry struct {
+	dir     bool
+	content []byte
+	modTime time.Time
+}
+
+var (
+	_ WritableFS   = (*MemFS)(nil)
+	_ fs.ReadDirFS = (*MemFS)(nil)
+	_ fs.StatFS    = (*MemFS)(nil)
+)
+
+// NewMemFS creates an empty in-memory WritableFS.
+func NewMemFS() *MemFS {
+	return &MemFS{files: map[string]*memEntry{".": {dir: true, modTime: time.Now()}}}
+}
+
+// Open implements fs.FS.
+func (m *MemFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	e, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	info := memFileInfo{name: path.Base(name), entry: e}
+
+	if e.dir {
+		return &memDirFile{fs: m, name: name, info: info}, nil
+	}
+	return &memFile{info: info, r: bytes.NewReader(e.content)}, nil
+}
+
+// Stat implements fs.StatFS.
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	e, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memFileInfo{name: path.Base(name), entry: e}, nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	dir, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	if !dir.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: ErrNotDir}
+	}
+
+	var entries []fs.DirEntry
+	for p, e := range m.files {
+		if p == "." || path.Dir(p) != name {
+			continue
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(memFileInfo{name: path.Base(p), entry: e}))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	return entries, nil
+}
+
+// Create implements WritableFS. The returned writer buffers content in
+// memory and commits it to the file system on Close.
+func (m *MemFS) Create(name string) (io.WriteCloser, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrInvalid}
+	}
+	return &memWriter{fs: m, name: name}, nil
+}
+
+// Mkdir implements WritableFS.
+func (m *MemFS) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.files[name]; ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	m.files[name] = &memEntry{dir: true, modTime: time.Now()}
+	return nil
+}
+
+// Remove implements WritableFS.
+func (m *MemFS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.files[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(m.files, name)
+	return nil
+}
+
+type memFileInfo struct {
+	name  string
+	entry *memEntry
+}
+
+func (fi memFileInfo) Name() string { return fi.name }
+
+func (fi memFileInfo) Size() int64 {
+	if fi.entry.dir {
+		return 0
+	}
+	return int64(len(fi.entry.content))
+}
+
+func (fi memFileInfo) Mode() fs.FileMode {
+	if fi.entry.dir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+func (fi memFileInfo) ModTime() time.Time { return fi.entry.modTime }
+func (fi memFileInfo) IsDir() bool        { return fi.entry.dir }
+func (fi memFileInfo) Sys() any           { return nil }
+
+type memFile struct {
+	info memFileInfo
+	r    *bytes.Reader
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Read(p []byte) (int, error) { return f.r.Read(p) }
+func (f *memFile) Close() error               { return nil }
+
+type memDirFile struct {
+	fs      *MemFS
+	name    string
+	info    memFileInfo
+	entries []fs.DirEntry
+	read    bool // set once entries has been fetched, so a drained slice isn't mistaken for unfetched
+}
+
+func (f *memDirFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *memDirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: f.name, Err: ErrDir}
+}
+
+func (f *memDirFile) Close() error { return nil }
+
+func (f *memDirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.read {
+		entries, err := f.fs.ReadDir(f.name)
+		if err != nil {
+			return nil, err
+		}
+		f.entries = entries
+		f.read = true
+	}
+
+	if n <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(f.entries) {
+		n = len(f.entries)
+	}
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
+
+type memWriter struct {
+	fs   *MemFS
+	name string
+	buf  bytes.Buffer
+}
+
+func (w *memWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *memWriter) Close() error {
+	w.fs.mu.Lock()
+	defer w.fs.mu.Unlock()
+
+	w.fs.files[w.name] = &memEntry{content: append([]byte(nil), w.buf.Bytes()...), modTime: time.Now()}
+	return nil
+}
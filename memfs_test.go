@@ -0,0 +1,54 @@
+package tarfs
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemFSTestFS(t *testing.T) {
+	require := require.New(t)
+
+	m := NewMemFS()
+	w, err := m.Create("foo")
+	require.NoError(err)
+	_, err = w.Write([]byte("foo"))
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	require.NoError(fstest.TestFS(m, "foo"))
+}
+
+func TestMemFSReadDirExhaustedReturnsEOF(t *testing.T) {
+	require := require.New(t)
+
+	m := NewMemFS()
+	w, err := m.Create("foo")
+	require.NoError(err)
+	_, err = w.Write([]byte("foo"))
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	f, err := m.Open(".")
+	require.NoError(err)
+	defer f.Close()
+
+	dir, ok := f.(interface {
+		ReadDir(n int) ([]fs.DirEntry, error)
+	})
+	require.True(ok)
+
+	entries, err := dir.ReadDir(-1)
+	require.NoError(err)
+	require.Len(entries, 1)
+
+	entries, err = dir.ReadDir(-1)
+	require.NoError(err)
+	require.Empty(entries)
+
+	_, err = dir.ReadDir(1)
+	require.ErrorIs(err, io.EOF)
+}
@@ -0,0 +1,308 @@
+package tarfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// WritableFS is the subset of file system operations OverlayFS needs from
+// its upper, writable layer. MemFS provides an in-memory implementation;
+// a caller backed by a real directory can satisfy WritableFS with thin
+// wrappers around the os package.
+type WritableFS interface {
+	fs.FS
+	Create(name string) (io.WriteCloser, error)
+	Mkdir(name string, perm fs.FileMode) error
+	Remove(name string) error
+}
+
+// OverlayFS presents a read-only base (typically an *FS opened from a tar
+// archive) overlaid with a writable upper layer, following the
+// copy-on-write model of afero's copyOnWriteFs: reads consult upper first
+// and fall back to base; every write lands in upper, copying a file up from
+// base first if needed; deletes are recorded as whiteouts so the shadowed
+// entry in base stops being visible.
+type OverlayFS struct {
+	base  fs.FS
+	upper WritableFS
+
+	whiteouts map[string]bool
+}
+
+var (
+	_ fs.FS        = (*OverlayFS)(nil)
+	_ fs.ReadDirFS = (*OverlayFS)(nil)
+	_ fs.StatFS    = (*OverlayFS)(nil)
+)
+
+// Overlay builds an OverlayFS presenting base as the read-only lower layer
+// and upper as the writable layer that receives every create, write and
+// delete.
+func Overlay(base fs.FS, upper WritableFS) *OverlayFS {
+	return &OverlayFS{base: base, upper: upper, whiteouts: map[string]bool{}}
+}
+
+// Open implements fs.FS.
+func (o *OverlayFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if o.isWhitedOut(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	fi, err := o.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.IsDir() {
+		entries, err := o.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &overlayDir{name: name, info: fi, entries: entries}, nil
+	}
+
+	f, err := o.upper.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return o.base.Open(name)
+}
+
+// Stat implements fs.StatFS.
+func (o *OverlayFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if o.isWhitedOut(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	fi, err := fs.Stat(o.upper, name)
+	if err == nil {
+		return fi, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return fs.Stat(o.base, name)
+}
+
+// ReadDir implements fs.ReadDirFS, merging the entries found in base and
+// upper and hiding anything whited out.
+func (o *OverlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if o.isWhitedOut(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	byName := map[string]fs.DirEntry{}
+	found := false
+
+	if baseEntries, err := fs.ReadDir(o.base, name); err == nil {
+		found = true
+		for _, e := range baseEntries {
+			byName[e.Name()] = e
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if upperEntries, err := fs.ReadDir(o.upper, name); err == nil {
+		found = true
+		for _, e := range upperEntries {
+			byName[e.Name()] = e
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(byName))
+	for childName, e := range byName {
+		if o.whiteouts[path.Join(name, childName)] {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	return entries, nil
+}
+
+// Create creates name in the upper layer, copying up any missing parent
+// directories from base first, and returns a handle for writing its
+// content.
+func (o *OverlayFS) Create(name string) (io.WriteCloser, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if err := o.mkdirAllUpper(path.Dir(name)); err != nil {
+		return nil, err
+	}
+
+	w, err := o.upper.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(o.whiteouts, name)
+
+	return w, nil
+}
+
+// WriteFile is a convenience wrapper around Create for callers that already
+// have the whole content in memory.
+func (o *OverlayFS) WriteFile(name string, data []byte) error {
+	w, err := o.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Mkdir creates name as a directory in the upper layer.
+func (o *OverlayFS) Mkdir(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if err := o.mkdirAllUpper(path.Dir(name)); err != nil {
+		return err
+	}
+	if err := o.upper.Mkdir(name, 0o755); err != nil {
+		return err
+	}
+
+	delete(o.whiteouts, name)
+
+	return nil
+}
+
+// Remove deletes name, whether it lives in upper, base or both, by clearing
+// it from upper (if present) and recording a whiteout that hides it from
+// base for the rest of this OverlayFS's lifetime.
+func (o *OverlayFS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if _, err := o.Stat(name); err != nil {
+		return err
+	}
+
+	if err := o.upper.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	o.whiteouts[name] = true
+
+	return nil
+}
+
+// Rename moves a plain file from oldname to newname, copying it up into the
+// upper layer and whiting out oldname. Directories are not supported.
+func (o *OverlayFS) Rename(oldname, newname string) error {
+	fi, err := o.Stat(oldname)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrInvalid}
+	}
+
+	data, err := fs.ReadFile(o, oldname)
+	if err != nil {
+		return err
+	}
+	if err := o.WriteFile(newname, data); err != nil {
+		return err
+	}
+
+	return o.Remove(oldname)
+}
+
+// isWhitedOut reports whether name, or any of its ancestor directories, has
+// been removed, so Open, Stat and ReadDir stay consistent with Remove on a
+// directory: removing "dir1" must hide "dir1/file.txt" too, not just make
+// "dir1" itself disappear.
+func (o *OverlayFS) isWhitedOut(name string) bool {
+	for {
+		if o.whiteouts[name] {
+			return true
+		}
+		if name == "." {
+			return false
+		}
+		name = path.Dir(name)
+	}
+}
+
+func (o *OverlayFS) mkdirAllUpper(dir string) error {
+	if dir == "." {
+		return nil
+	}
+	if err := o.mkdirAllUpper(path.Dir(dir)); err != nil {
+		return err
+	}
+	if _, err := fs.Stat(o.upper, dir); err == nil {
+		return nil
+	}
+	if err := o.upper.Mkdir(dir, 0o755); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	return nil
+}
+
+// overlayDir is the fs.ReadDirFile returned by OverlayFS.Open for a
+// directory, serving the entries merged by OverlayFS.ReadDir.
+type overlayDir struct {
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+}
+
+func (d *overlayDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *overlayDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: ErrDir}
+}
+
+func (d *overlayDir) Close() error { return nil }
+
+func (d *overlayDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
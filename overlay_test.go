@@ -0,0 +1,126 @@
+package tarfs
+
+import (
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlayReadsFallThroughToBase(t *testing.T) {
+	require := require.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	base, err := New(f)
+	require.NoError(err)
+
+	ofs := Overlay(base, NewMemFS())
+
+	b, err := fs.ReadFile(ofs, "foo")
+	require.NoError(err)
+	require.Equal("foo", string(b))
+}
+
+func TestOverlayWriteFileShadowsBase(t *testing.T) {
+	require := require.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	base, err := New(f)
+	require.NoError(err)
+
+	ofs := Overlay(base, NewMemFS())
+
+	require.NoError(ofs.WriteFile("foo", []byte("overwritten")))
+
+	b, err := fs.ReadFile(ofs, "foo")
+	require.NoError(err)
+	require.Equal("overwritten", string(b))
+}
+
+func TestOverlayRemoveHidesBaseEntry(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	base, err := New(f)
+	require.NoError(err)
+
+	ofs := Overlay(base, NewMemFS())
+
+	require.NoError(ofs.Remove("foo"))
+
+	_, err = fs.Stat(ofs, "foo")
+	assert.ErrorIs(err, fs.ErrNotExist)
+
+	entries, err := fs.ReadDir(ofs, ".")
+	require.NoError(err)
+	for _, e := range entries {
+		assert.NotEqual("foo", e.Name())
+	}
+}
+
+func TestOverlayRemoveDirectoryHidesDescendants(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	base, err := New(f)
+	require.NoError(err)
+
+	ofs := Overlay(base, NewMemFS())
+
+	require.NoError(ofs.Remove("dir1"))
+
+	_, err = fs.Stat(ofs, "dir1")
+	assert.ErrorIs(err, fs.ErrNotExist)
+
+	_, err = ofs.ReadDir("dir1")
+	assert.ErrorIs(err, fs.ErrNotExist)
+
+	_, err = ofs.Open("dir1/file11")
+	assert.ErrorIs(err, fs.ErrNotExist)
+}
+
+func TestOverlayCreateNewFile(t *testing.T) {
+	require := require.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	base, err := New(f)
+	require.NoError(err)
+
+	ofs := Overlay(base, NewMemFS())
+
+	require.NoError(ofs.WriteFile("new.txt", []byte("hello")))
+
+	b, err := fs.ReadFile(ofs, "new.txt")
+	require.NoError(err)
+	require.Equal("hello", string(b))
+
+	entries, err := fs.ReadDir(ofs, ".")
+	require.NoError(err)
+
+	var found bool
+	for _, e := range entries {
+		if e.Name() == "new.txt" {
+			found = true
+		}
+	}
+	require.True(found)
+}
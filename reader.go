@@ -0,0 +1,18 @@
+package tarfs
+
+import "io"
+
+// countingReader wraps an io.Reader and tracks the number of bytes read from
+// it so far. NewFromReaderAt uses it to recover the byte offset of each
+// entry's body inside the archive, since archive/tar does not expose it
+// directly.
+type countingReader struct {
+	r   io.Reader
+	pos int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.pos += int64(n)
+	return n, err
+}
@@ -0,0 +1,111 @@
+package tarfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFromReaderAtRejectsDecompressor(t *testing.T) {
+	require := require.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	fi, err := f.Stat()
+	require.NoError(err)
+
+	_, err = NewFromReaderAt(f, fi.Size(), WithDecompressor(func(r io.Reader) (io.Reader, error) { return r, nil }))
+	require.Error(err)
+}
+
+func TestNewFromFile(t *testing.T) {
+	require := require.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	tfs, err := NewFromFile(f)
+	require.NoError(err)
+
+	err = fstest.TestFS(tfs, "foo", "dir1/dir11")
+	require.NoError(err)
+}
+
+func TestNewFromReaderAtReadFile(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	fi, err := f.Stat()
+	require.NoError(err)
+
+	tfs, err := NewFromReaderAt(f, fi.Size())
+	require.NoError(err)
+
+	for name, content := range map[string]string{
+		"dir1/dir11/file111": "file111",
+		"dir2/dir21/file212": "file212",
+		"foo":                "foo",
+	} {
+		b, err := fs.ReadFile(tfs, name)
+		if !assert.NoErrorf(err, "when fs.ReadFile(tfs, %#v)", name) {
+			continue
+		}
+
+		assert.Equalf(content, string(b), "in %#v", name)
+	}
+}
+
+func TestNewFromReaderAtConcurrentReadAt(t *testing.T) {
+	require := require.New(t)
+
+	f, err := os.Open("test.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	fi, err := f.Stat()
+	require.NoError(err)
+
+	tfs, err := NewFromReaderAt(f, fi.Size())
+	require.NoError(err)
+
+	done := make(chan error, 2)
+	for _, name := range []string{"foo", "bar"} {
+		name := name
+		go func() {
+			rf, err := tfs.Open(name)
+			if err != nil {
+				done <- err
+				return
+			}
+			defer rf.Close()
+
+			ra, ok := rf.(interface {
+				ReadAt([]byte, int64) (int, error)
+			})
+			if !ok {
+				done <- nil
+				return
+			}
+
+			buf := make([]byte, 1)
+			_, err = ra.ReadAt(buf, 0)
+			done <- err
+		}()
+	}
+
+	for range []string{"foo", "bar"} {
+		require.NoError(<-done)
+	}
+}
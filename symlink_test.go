@@ -0,0 +1,94 @@
+package tarfs
+
+import (
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadLink(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	f, err := os.Open("test-with-symlinks.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	tfs, err := New(f)
+	require.NoError(err)
+
+	target, err := tfs.ReadLink("link-to-foo")
+	require.NoError(err)
+	assert.Equal("foo", target)
+
+	_, err = tfs.ReadLink("foo")
+	assert.ErrorIs(err, fs.ErrInvalid)
+}
+
+func TestOpenWithSymlinksResolvesTarget(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	f, err := os.Open("test-with-symlinks.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	tfs, err := New(f, WithSymlinks(true))
+	require.NoError(err)
+
+	b, err := fs.ReadFile(tfs, "link-to-foo")
+	require.NoError(err)
+	assert.Equal("foo", string(b))
+}
+
+func TestOpenSymlinkEscapingRootIsInvalid(t *testing.T) {
+	require := require.New(t)
+
+	f, err := os.Open("test-with-symlinks.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	tfs, err := New(f, WithSymlinks(true))
+	require.NoError(err)
+
+	_, err = tfs.Open("link-outside-root")
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+}
+
+func TestWalkDirFollowsDirectorySymlink(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	f, err := os.Open("test-with-symlinks.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	tfs, err := New(f, WithSymlinks(true))
+	require.NoError(err)
+
+	var paths []string
+	require.NoError(fs.WalkDir(tfs, ".", func(path string, d fs.DirEntry, err error) error {
+		require.NoError(err)
+		paths = append(paths, path)
+		return nil
+	}))
+
+	assert.Contains(paths, "linkdir/file.txt")
+}
+
+func TestOpenSymlinkCycleIsTooManyLinks(t *testing.T) {
+	require := require.New(t)
+
+	f, err := os.Open("test-with-symlink-cycle.tar")
+	require.NoError(err)
+	defer f.Close()
+
+	tfs, err := New(f, WithSymlinks(true))
+	require.NoError(err)
+
+	_, err = tfs.Open("a")
+	assert.ErrorIs(t, err, ErrTooManyLinks)
+}